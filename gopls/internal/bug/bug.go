@@ -12,11 +12,14 @@
 package bug
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"runtime"
 	"runtime/debug"
 	"sort"
 	"sync"
+	"time"
 )
 
 // PanicOnBugs controls whether to panic when bugs are reported.
@@ -30,14 +33,67 @@ var (
 	waiters   []chan<- Bug
 )
 
+// A Severity indicates how serious a bug is, for the purpose of triaging
+// exemplars in the `/bugs` debug page.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "Info"
+	case SeverityWarning:
+		return "Warning"
+	case SeverityCritical:
+		return "Critical"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
 // A Bug represents an unexpected event or broken invariant. They are used for
 // capturing metadata that helps us understand the event.
 type Bug struct {
-	File        string // file containing the call to bug.Report
-	Line        int    // line containing the call to bug.Report
-	Description string // description of the bug
-	Key         string // key identifying the bug (file:line if available)
-	Stack       string // call stack
+	File        string         // file containing the call to bug.Report
+	Line        int            // line containing the call to bug.Report
+	Description string         // description of the bug
+	Key         string         // key identifying the bug (file:line if available)
+	Stack       string         // call stack
+	Severity    Severity       // how serious the bug is
+	Count       int            // number of times this key has been reported
+	FirstTime   time.Time      // time of the first report of this key
+	LastTime    time.Time      // time of the most recent report of this key
+	Data        map[string]any // structured metadata attached via ReportWith
+}
+
+// An Option configures the metadata attached to a reported Bug.
+type Option func(*Bug)
+
+// WithSeverity sets the severity of the reported bug. The default severity
+// is SeverityWarning.
+func WithSeverity(severity Severity) Option {
+	return func(b *Bug) { b.Severity = severity }
+}
+
+// WithData attaches a labeled piece of metadata to the reported bug, such as
+// a snapshot ID or view name. Callers are responsible for ensuring that data
+// does not contain sensitive user content; redact before calling.
+func WithData(label string, data any) Option {
+	return func(b *Bug) {
+		if b.Data == nil {
+			b.Data = make(map[string]any)
+		}
+		b.Data[label] = data
+	}
 }
 
 // Reportf reports a formatted bug message.
@@ -59,7 +115,14 @@ func Report(description string) {
 	report(description)
 }
 
-func report(description string) {
+// ReportWith records a new bug, along with structured metadata attached via
+// opts. Use this variant when the report should carry a non-default severity
+// or labeled data for later triage.
+func ReportWith(description string, opts ...Option) {
+	report(description, opts...)
+}
+
+func report(description string, opts ...Option) {
 	_, file, line, ok := runtime.Caller(2) // all exported reporting functions call report directly
 
 	key := "<missing callsite>"
@@ -71,29 +134,51 @@ func report(description string) {
 		panic(fmt.Sprintf("%s: %s", key, description))
 	}
 
+	now := time.Now()
 	bug := Bug{
 		File:        file,
 		Line:        line,
 		Description: description,
 		Key:         key,
 		Stack:       string(debug.Stack()),
+		Severity:    SeverityWarning,
+		Count:       1,
+		FirstTime:   now,
+		LastTime:    now,
+	}
+	for _, opt := range opts {
+		opt(&bug)
 	}
 
 	mu.Lock()
-	defer mu.Unlock()
 
 	if exemplars == nil {
 		exemplars = make(map[string]Bug)
 	}
 
-	if _, ok := exemplars[key]; !ok {
-		exemplars[key] = bug // capture one exemplar per key
+	if existing, ok := exemplars[key]; ok {
+		// Keep the first exemplar's metadata and stack, but track the
+		// occurrence count and most recent sighting.
+		existing.Count++
+		existing.LastTime = now
+		exemplars[key] = existing
+		// Waiters and handlers see the running count for this key.
+		bug.Count = existing.Count
+		bug.FirstTime = existing.FirstTime
+	} else {
+		exemplars[key] = bug
 	}
 
 	for _, waiter := range waiters {
 		waiter <- bug
 	}
 	waiters = nil
+
+	mu.Unlock()
+
+	// Handlers may do I/O (writing to a file, sending an LSP notification),
+	// so fan out to them outside the lock.
+	notifyHandlers(bug)
 }
 
 // Notify returns a channel that will be sent the next bug to occur on the
@@ -125,3 +210,26 @@ func List() []Bug {
 
 	return bugs
 }
+
+// MarshalJSON marshals the list of bug exemplars, as a JSON array sorted by
+// callsite key (see List), so that it can be served by the gopls /bugs debug
+// page.
+func MarshalJSON() ([]byte, error) {
+	return json.Marshal(List())
+}
+
+// DebugHandler serves the current bug exemplars as JSON. Mount it on gopls'
+// /debug server to inspect bugs reported by a running instance.
+//
+//	mux.Handle("/bugs", bug.DebugHandler())
+func DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := MarshalJSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSeverityString(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		want     string
+	}{
+		{SeverityInfo, "Info"},
+		{SeverityWarning, "Warning"},
+		{SeverityCritical, "Critical"},
+		{Severity(99), "Severity(99)"},
+	}
+	for _, test := range tests {
+		if got := test.severity.String(); got != test.want {
+			t.Errorf("Severity(%d).String() = %q, want %q", test.severity, got, test.want)
+		}
+	}
+}
+
+func TestSeverityMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(SeverityCritical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `"Critical"`; got != want {
+		t.Errorf("json.Marshal(SeverityCritical) = %s, want %s", got, want)
+	}
+}
+
+// TestReportDedupsByKey guards against regressing the Count/FirstTime/
+// LastTime bookkeeping in report: repeated reports from the same callsite
+// should collapse into a single exemplar with an incrementing Count, not
+// produce a new exemplar each time.
+func TestReportDedupsByKey(t *testing.T) {
+	exemplars = nil // reset package state between tests
+
+	report("dup bug")
+	report("dup bug")
+	report("dup bug")
+
+	bugs := List()
+	if len(bugs) != 1 {
+		t.Fatalf("got %d exemplars, want 1", len(bugs))
+	}
+	if got := bugs[0].Count; got != 3 {
+		t.Errorf("Count = %d, want 3", got)
+	}
+	if bugs[0].FirstTime.After(bugs[0].LastTime) {
+		t.Errorf("FirstTime %v is after LastTime %v", bugs[0].FirstTime, bugs[0].LastTime)
+	}
+}
+
+func TestReportWithOptions(t *testing.T) {
+	exemplars = nil
+
+	ReportWith("bug with options", WithSeverity(SeverityCritical), WithData("key", "value"))
+
+	bugs := List()
+	if len(bugs) != 1 {
+		t.Fatalf("got %d exemplars, want 1", len(bugs))
+	}
+	if bugs[0].Severity != SeverityCritical {
+		t.Errorf("Severity = %v, want %v", bugs[0].Severity, SeverityCritical)
+	}
+	if got := bugs[0].Data["key"]; got != "value" {
+		t.Errorf("Data[%q] = %v, want %q", "key", got, "value")
+	}
+}
+
+func TestMarshalJSONAndDebugHandler(t *testing.T) {
+	exemplars = nil
+	report("bug for json")
+
+	data, err := MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Severity only marshals (to its String form), so decode generically
+	// rather than round-tripping through Bug.
+	var bugs []map[string]any
+	if err := json.Unmarshal(data, &bugs); err != nil {
+		t.Fatalf("unmarshaling MarshalJSON output: %v", err)
+	}
+	if len(bugs) != 1 || bugs[0]["Description"] != "bug for json" {
+		t.Fatalf("MarshalJSON round-trip = %+v, want a single bug with Description %q", bugs, "bug for json")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/bugs", nil)
+	rec := httptest.NewRecorder()
+	DebugHandler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DebugHandler status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var served []map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("unmarshaling DebugHandler response: %v", err)
+	}
+	if len(served) != 1 || served[0]["Description"] != "bug for json" {
+		t.Fatalf("DebugHandler response = %+v, want a single bug with Description %q", served, "bug for json")
+	}
+}
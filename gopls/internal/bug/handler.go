@@ -0,0 +1,178 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bug
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// A Handler is a durable sink that bugs are forwarded to as they are
+// reported, in addition to being recorded as in-process exemplars. Register
+// one with AddHandler: see NewFileHandler, NewSlogHandler, and
+// NewNotifyHandler for the handlers this package provides.
+type Handler interface {
+	Handle(Bug)
+}
+
+// A HandlerFunc is a Handler that calls itself.
+type HandlerFunc func(Bug)
+
+func (f HandlerFunc) Handle(b Bug) { f(b) }
+
+// A HandlerToken identifies a Handler previously registered with AddHandler,
+// so that it can later be unregistered with RemoveHandler. Handler
+// implementations are not required to be comparable (HandlerFunc, in
+// particular, is not: comparing two non-comparable func values panics), so
+// the token -- not the Handler itself -- is what RemoveHandler matches on.
+type HandlerToken struct {
+	handler Handler
+}
+
+var (
+	handlersMu sync.Mutex
+	handlers   []*HandlerToken
+	limiters   = map[string]*tokenBucket{}
+)
+
+// AddHandler registers h to be notified of every reported bug, subject to
+// per-key rate limiting, and returns a token that can be passed to
+// RemoveHandler to unregister it. Handlers run synchronously with reporting
+// but outside of bug's internal lock, so a slow handler delays other
+// handlers but never blocks bug.Report itself from recording its exemplar.
+func AddHandler(h Handler) *HandlerToken {
+	tok := &HandlerToken{handler: h}
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers = append(handlers, tok)
+	return tok
+}
+
+// RemoveHandler unregisters the handler identified by tok, as returned by
+// AddHandler.
+func RemoveHandler(tok *HandlerToken) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	for i, existing := range handlers {
+		if existing == tok {
+			handlers = append(handlers[:i], handlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyHandlers forwards bug to every registered handler, unless the rate
+// limit for bug.Key (shared by all handlers) has been exhausted.
+func notifyHandlers(bug Bug) {
+	handlersMu.Lock()
+	if !allow(bug.Key) {
+		handlersMu.Unlock()
+		return
+	}
+	toks := append([]*HandlerToken(nil), handlers...)
+	handlersMu.Unlock()
+
+	for _, tok := range toks {
+		tok.handler.Handle(bug)
+	}
+}
+
+// allow reports whether a bug with the given key may be forwarded to
+// handlers right now, consuming a token from its bucket if so. Callers must
+// hold handlersMu.
+func allow(key string) bool {
+	const (
+		ratePerSecond = 1.0
+		burst         = 10
+	)
+	b, ok := limiters[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: time.Now()}
+		limiters[key] = b
+	}
+	return b.take(ratePerSecond, burst)
+}
+
+// A tokenBucket is a simple token-bucket rate limiter, used to keep a hot
+// bug loop from flooding registered handlers.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(ratePerSecond float64, burst int) bool {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * ratePerSecond
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Flush gives registered handlers a chance to persist pending output before
+// the process exits (FileHandler syncs its file to disk). Handlers that
+// implement flusher are flushed; others are a no-op.
+func Flush(ctx context.Context) error {
+	handlersMu.Lock()
+	toks := append([]*HandlerToken(nil), handlers...)
+	handlersMu.Unlock()
+
+	var firstErr error
+	for _, tok := range toks {
+		if f, ok := tok.handler.(flusher); ok {
+			if err := f.Flush(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// FileHandler is a Handler that appends each bug to a file as a line of
+// JSON, for offline inspection.
+type FileHandler struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileHandler opens (creating if necessary) path for appending, and
+// returns a Handler that writes each bug to it as a JSONL record.
+func NewFileHandler(path string) (*FileHandler, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileHandler{f: f}, nil
+}
+
+func (h *FileHandler) Handle(b Bug) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.f.Write(data)
+}
+
+func (h *FileHandler) Flush(context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.f.Sync()
+}
@@ -0,0 +1,30 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bug
+
+import "testing"
+
+// TestAddRemoveHandlerFunc guards against a regression where RemoveHandler
+// compared Handler values with ==: HandlerFunc wraps a func, which is not
+// comparable, so doing so panics for any HandlerFunc-based handler
+// (including the one returned by NewSlogHandler).
+func TestAddRemoveHandlerFunc(t *testing.T) {
+	var got []Bug
+	tok := AddHandler(HandlerFunc(func(b Bug) {
+		got = append(got, b)
+	}))
+
+	report("bug before removal")
+	if len(got) != 1 {
+		t.Fatalf("got %d bugs after first report, want 1", len(got))
+	}
+
+	RemoveHandler(tok)
+
+	report("bug after removal")
+	if len(got) != 1 {
+		t.Fatalf("got %d bugs after removal, want still 1 (handler should no longer fire)", len(got))
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bug
+
+// A Notifier delivers a single bug report to the LSP client, e.g. as a
+// window/logMessage or telemetry/event notification. This package provides
+// no Notifier implementation and registers none; the sending is left to an
+// implementation that owns the client connection, so that this package
+// never needs to depend on the LSP protocol types.
+type Notifier interface {
+	Notify(b Bug)
+}
+
+// NewNotifyHandler returns a Handler that forwards each reported bug to n.
+// Register it with AddHandler at server startup to surface bugs to the
+// client, alongside (or instead of) NewFileHandler and NewSlogHandler.
+func NewNotifyHandler(n Notifier) Handler {
+	return HandlerFunc(func(b Bug) { n.Notify(b) })
+}
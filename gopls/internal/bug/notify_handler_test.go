@@ -0,0 +1,27 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bug
+
+import "testing"
+
+type fakeNotifier struct {
+	got []Bug
+}
+
+func (n *fakeNotifier) Notify(b Bug) { n.got = append(n.got, b) }
+
+func TestNewNotifyHandler(t *testing.T) {
+	n := &fakeNotifier{}
+	tok := AddHandler(NewNotifyHandler(n))
+	defer RemoveHandler(tok)
+
+	report("bug for notifier")
+	if len(n.got) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(n.got))
+	}
+	if n.got[0].Description != "bug for notifier" {
+		t.Errorf("notified bug description = %q, want %q", n.got[0].Description, "bug for notifier")
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.21
+
+package bug
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogHandler returns a Handler that logs each bug to logger at
+// slog.LevelError, tagged with its key, severity, and occurrence count.
+func NewSlogHandler(logger *slog.Logger) Handler {
+	return HandlerFunc(func(b Bug) {
+		logger.LogAttrs(context.Background(), slog.LevelError, b.Description,
+			slog.String("key", b.Key),
+			slog.String("severity", b.Severity.String()),
+			slog.Int("count", b.Count),
+		)
+	})
+}
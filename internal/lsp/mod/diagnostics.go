@@ -8,6 +8,7 @@ package mod
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -21,6 +22,14 @@ import (
 	"golang.org/x/tools/internal/span"
 )
 
+// Diagnostics, ExtractGoCommandError, and their helpers below populate
+// source.Diagnostic.Related with source.RelatedInformation entries (e.g.
+// linking an indirect-dependency warning back to the require line that
+// pulls the package in transitively, mirroring the type-checker's
+// "previous declaration here" secondary errors). That field, and the
+// mapping of it through to protocol.Diagnostic.RelatedInformation when a
+// diagnostic is published to the client, live in package source, which
+// this package depends on but does not define.
 func Diagnostics(ctx context.Context, snapshot source.Snapshot) (map[source.FileIdentity][]*source.Diagnostic, error) {
 	uri := snapshot.View().ModFile()
 	if uri == "" {
@@ -48,6 +57,12 @@ func Diagnostics(ctx context.Context, snapshot source.Snapshot) (map[source.File
 	if err != nil {
 		return nil, err
 	}
+	parsed, m, requirer, err := parseModForRelatedInfo(ctx, snapshot, fh)
+	if err != nil {
+		// We can still produce diagnostics without related information, so
+		// don't fail the whole pass if go.mod couldn't be parsed.
+		parsed, m, requirer = nil, nil, nil
+	}
 	for _, e := range diagnostics {
 		diag := &source.Diagnostic{
 			Message: e.Message,
@@ -59,6 +74,16 @@ func Diagnostics(ctx context.Context, snapshot source.Snapshot) (map[source.File
 		} else {
 			diag.Severity = protocol.SeverityWarning
 		}
+		// go.mod: found module providing package X as an indirect
+		// dependency of Y -- link the diagnostic back to the require line
+		// that actually pulls the package in transitively.
+		if parsed != nil {
+			if path, ok := indirectDependencyPackage(e.Message); ok {
+				if rel, ok := relatedRequireInformation(parsed, fh.URI(), m, requirer(path)); ok {
+					diag.Related = append(diag.Related, rel)
+				}
+			}
+		}
 		fh, err := snapshot.GetFile(ctx, e.URI)
 		if err != nil {
 			return nil, err
@@ -68,6 +93,80 @@ func Diagnostics(ctx context.Context, snapshot source.Snapshot) (map[source.File
 	return reports, nil
 }
 
+var indirectDependencyPackageRe = regexp.MustCompile(`found module providing package (\S+)`)
+
+// indirectDependencyPackage extracts the package path from a go mod tidy
+// message about an indirectly-required package, if any.
+func indirectDependencyPackage(message string) (string, bool) {
+	match := indirectDependencyPackageRe.FindStringSubmatch(message)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// packageWithinModule reports whether pkg is a package path provided by
+// module modPath, i.e. it is modPath itself or a subdirectory of it. A plain
+// strings.HasPrefix is not enough here: it would also match "example.com/foo"
+// against the unrelated package "example.com/foobar/baz".
+func packageWithinModule(pkg, modPath string) bool {
+	return pkg == modPath || strings.HasPrefix(pkg, modPath+"/")
+}
+
+// parseModForRelatedInfo parses fh's go.mod and returns a function mapping a
+// package path to the module path that most plausibly requires it
+// transitively (the longest require path prefix match), for use in
+// RelatedInformation.
+func parseModForRelatedInfo(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) (*modfile.File, *protocol.ColumnMapper, func(string) string, error) {
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	parsed, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	requirer := func(pkg string) string {
+		var best string
+		for _, req := range parsed.Require {
+			if packageWithinModule(pkg, req.Mod.Path) && len(req.Mod.Path) > len(best) {
+				best = req.Mod.Path
+			}
+		}
+		return best
+	}
+	return parsed, m, requirer, nil
+}
+
+// relatedRequireInformation builds a RelatedInformation entry pointing at the
+// require line for modPath, if one exists.
+func relatedRequireInformation(parsed *modfile.File, uri span.URI, m *protocol.ColumnMapper, modPath string) (source.RelatedInformation, bool) {
+	if modPath == "" {
+		return source.RelatedInformation{}, false
+	}
+	for _, req := range parsed.Require {
+		if req.Mod.Path != modPath {
+			continue
+		}
+		rng, err := rangeFromPositions(uri, m, req.Syntax.Start, req.Syntax.End)
+		if err != nil {
+			return source.RelatedInformation{}, false
+		}
+		return source.RelatedInformation{
+			URI:     uri,
+			Range:   rng,
+			Message: fmt.Sprintf("%s is required here", modPath),
+		}, true
+	}
+	return source.RelatedInformation{}, false
+}
+
+// SuggestedFixes returns the code actions available for diags, drawing on
+// three independent sources of go.mod diagnostics: go mod tidy (via
+// ModTidyHandle), go command errors (via ExtractGoCommandError), and known
+// vulnerabilities (via VulnDiagnostics). Only fixes that carry real Edits
+// are surfaced as code actions -- a fix with no edits would otherwise appear
+// as a quick fix that silently does nothing when invoked.
 func SuggestedFixes(ctx context.Context, snapshot source.Snapshot, diags []protocol.Diagnostic) ([]protocol.CodeAction, error) {
 	mth, err := snapshot.ModTidyHandle(ctx)
 	if err == source.ErrTmpModfileUnsupported {
@@ -76,71 +175,312 @@ func SuggestedFixes(ctx context.Context, snapshot source.Snapshot, diags []proto
 	if err != nil {
 		return nil, err
 	}
-	diagnostics, err := mth.Tidy(ctx)
+	tidyDiagnostics, err := mth.Tidy(ctx)
 	if err != nil {
 		return nil, err
 	}
 	errorsMap := make(map[string][]source.Error)
-	for _, e := range diagnostics {
-		if errorsMap[e.Message] == nil {
-			errorsMap[e.Message] = []source.Error{}
-		}
+	for _, e := range tidyDiagnostics {
 		errorsMap[e.Message] = append(errorsMap[e.Message], e)
 	}
+
+	// go-command-error and vulnerability diagnostics carry their
+	// SuggestedFixes directly on the *source.Diagnostic (there's no
+	// intermediate source.Error, since they don't come from `go mod tidy`);
+	// collect them keyed by message the same way, so their fixes surface
+	// too.
+	extra, err := extraDiagnosticsByMessage(ctx, snapshot, diags)
+	if err != nil {
+		return nil, err
+	}
+
 	var actions []protocol.CodeAction
 	for _, diag := range diags {
 		for _, e := range errorsMap[diag.Message] {
 			if !sameDiagnostic(diag, e) {
 				continue
 			}
-			for _, fix := range e.SuggestedFixes {
-				action := protocol.CodeAction{
-					Title:       fix.Title,
-					Kind:        protocol.QuickFix,
-					Diagnostics: []protocol.Diagnostic{diag},
-					Edit:        protocol.WorkspaceEdit{},
-				}
-				for uri, edits := range fix.Edits {
-					fh, err := snapshot.GetFile(ctx, uri)
-					if err != nil {
-						return nil, err
-					}
-					action.Edit.DocumentChanges = append(action.Edit.DocumentChanges, protocol.TextDocumentEdit{
-						TextDocument: protocol.VersionedTextDocumentIdentifier{
-							Version: fh.Version(),
-							TextDocumentIdentifier: protocol.TextDocumentIdentifier{
-								URI: protocol.URIFromSpanURI(fh.URI()),
-							},
-						},
-						Edits: edits,
-					})
-				}
-				actions = append(actions, action)
+			fixes, err := codeActionsForFixes(ctx, snapshot, diag, e.SuggestedFixes)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, fixes...)
+		}
+		for _, d := range extra[diag.Message] {
+			if protocol.CompareRange(diag.Range, d.Range) != 0 {
+				continue
+			}
+			fixes, err := codeActionsForFixes(ctx, snapshot, diag, d.SuggestedFixes)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, fixes...)
+		}
+	}
+	return actions, nil
+}
+
+// codeActionsForFixes converts fixes into protocol.CodeActions anchored at
+// diag. Fixes with no Edits are dropped rather than published: without a
+// registered LSP command to dispatch to, they would do nothing when
+// invoked.
+func codeActionsForFixes(ctx context.Context, snapshot source.Snapshot, diag protocol.Diagnostic, fixes []source.SuggestedFix) ([]protocol.CodeAction, error) {
+	var actions []protocol.CodeAction
+	for _, fix := range fixes {
+		if len(fix.Edits) == 0 {
+			continue
+		}
+		action := protocol.CodeAction{
+			Title:       fix.Title,
+			Kind:        protocol.QuickFix,
+			Diagnostics: []protocol.Diagnostic{diag},
+			Edit:        protocol.WorkspaceEdit{},
+		}
+		for uri, edits := range fix.Edits {
+			fh, err := snapshot.GetFile(ctx, uri)
+			if err != nil {
+				return nil, err
 			}
+			action.Edit.DocumentChanges = append(action.Edit.DocumentChanges, protocol.TextDocumentEdit{
+				TextDocument: protocol.VersionedTextDocumentIdentifier{
+					Version: fh.Version(),
+					TextDocumentIdentifier: protocol.TextDocumentIdentifier{
+						URI: protocol.URIFromSpanURI(fh.URI()),
+					},
+				},
+				Edits: edits,
+			})
 		}
+		actions = append(actions, action)
 	}
 	return actions, nil
 }
 
+// extraDiagnosticsByMessage re-derives the go-command-error and
+// vulnerability diagnostics for the view's go.mod, keyed by message, so that
+// diags passed into SuggestedFixes can be matched against them the same way
+// they're matched against `go mod tidy`'s errorsMap above.
+//
+// A go-command-error diagnostic's Message is exactly the original error
+// text (see toDiagnostic), so feeding diag.Message back through
+// ExtractGoCommandError regenerates the same diagnostic, SuggestedFixes
+// included.
+func extraDiagnosticsByMessage(ctx context.Context, snapshot source.Snapshot, diags []protocol.Diagnostic) (map[string][]*source.Diagnostic, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil
+	}
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	byMessage := make(map[string][]*source.Diagnostic)
+	for _, diag := range diags {
+		if _, ok := byMessage[diag.Message]; ok {
+			continue
+		}
+		if d, err := ExtractGoCommandError(ctx, snapshot, fh, errors.New(diag.Message)); err == nil {
+			byMessage[diag.Message] = append(byMessage[diag.Message], d)
+		}
+	}
+
+	vulnReports, err := VulnDiagnostics(ctx, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	for _, vulnDiags := range vulnReports {
+		for _, d := range vulnDiags {
+			byMessage[d.Message] = append(byMessage[d.Message], d)
+		}
+	}
+	return byMessage, nil
+}
+
 func sameDiagnostic(d protocol.Diagnostic, e source.Error) bool {
 	return d.Message == e.Message && protocol.CompareRange(d.Range, e.Range) == 0 && d.Source == e.Category
 }
 
 var moduleAtVersionRe = regexp.MustCompile(`(?P<module>.*)@(?P<version>.*)`)
 
+// goCommandErrorContext bundles the arguments that a matcher's classify
+// function needs to turn a regexp match into a go.mod diagnostic.
+type goCommandErrorContext struct {
+	ctx      context.Context
+	snapshot source.Snapshot
+	fh       source.FileHandle
+	parsed   *modfile.File
+	mapper   *protocol.ColumnMapper
+	loadErr  error
+}
+
+// A goCommandErrorMatcher recognizes one shape of error that the go command
+// can produce while loading a module, and turns it into a diagnostic
+// anchored at the relevant go.mod line(s). name identifies the matcher for
+// tests; classify funcs aren't comparable, so name is what lets a test
+// assert which matcher fired.
+type goCommandErrorMatcher struct {
+	name     string
+	pattern  *regexp.Regexp
+	classify func(goCommandErrorContext, []string) (*source.Diagnostic, error)
+}
+
+// goCommandErrorMatchers is ordered from most to least specific: several
+// go command errors mention a bare module@version substring as part of a
+// longer, more specific message (e.g. checksum mismatches), so the generic
+// moduleAtVersionRe catch-all must run last or it will shadow them.
+var goCommandErrorMatchers = []*goCommandErrorMatcher{
+	// "missing go.sum entry for module providing package golang.org/x/mod
+	// (imported by example.com/foo); to add: go mod download golang.org/x/mod"
+	{
+		"missingGoSumEntry",
+		regexp.MustCompile(`missing go.sum entry for module providing package (?P<package>\S+)`),
+		classifyMissingGoSumEntry,
+	},
+
+	// "ambiguous import: found package example.com/foo in multiple modules:
+	//     example.com/foo v1.0.0 (/path/to/a)
+	//     example.com/bar v1.0.0 (/path/to/b)"
+	{
+		"ambiguousImport",
+		regexp.MustCompile(`ambiguous import: found package (?P<package>\S+) in multiple modules`),
+		classifyAmbiguousImport,
+	},
+
+	// "replacement module example.com/foo has different version v1.2.0 from
+	// go.mod", and replace cycles reported as "cyclic replace" or "cycle in
+	// replace"
+	{
+		"replaceError",
+		regexp.MustCompile(`replacement module (?P<module>\S+) has different version (?P<version>\S+) from go\.mod|(cyclic|cycle in) replace`),
+		classifyReplaceError,
+	},
+
+	// "verifying module: example.com/foo@v1.2.3: checksum mismatch
+	//     downloaded: h1:...
+	//     go.sum:     h1:..."
+	{
+		"checksumMismatch",
+		regexp.MustCompile(`verifying (?:module: )?(?P<module>\S+)@(?P<version>\S+): checksum mismatch`),
+		classifyChecksumMismatch,
+	},
+
+	// "go: example.com@v1.2.2: reading example.com/@v/v1.2.2.mod: no such file or directory"
+	// "go: github.com/cockroachdb/apd/v2@v2.0.72: ... unknown revision v2.0.72"
+	//
+	// This is a catch-all: it matches any message containing a
+	// module@version substring, which includes the messages matched above,
+	// so it must stay last in the table.
+	{"moduleAtVersion", moduleAtVersionRe, classifyModuleAtVersion},
+}
+
+// matchGoCommandErrors returns every matcher in goCommandErrorMatchers whose
+// pattern matches msg, in table order, along with its submatches. It is
+// split out from ExtractGoCommandError so that the matcher-selection logic
+// (in particular, that the generic moduleAtVersionRe catch-all doesn't
+// shadow more specific matchers) can be tested without a source.Snapshot.
+func matchGoCommandErrors(msg string) []struct {
+	matcher *goCommandErrorMatcher
+	match   []string
+} {
+	var matches []struct {
+		matcher *goCommandErrorMatcher
+		match   []string
+	}
+	for _, matcher := range goCommandErrorMatchers {
+		if match := matcher.pattern.FindStringSubmatch(msg); match != nil {
+			matches = append(matches, struct {
+				matcher *goCommandErrorMatcher
+				match   []string
+			}{matcher, match})
+		}
+	}
+	return matches
+}
+
 // ExtractGoCommandError tries to parse errors that come from the go command
 // and shape them into go.mod diagnostics.
 func ExtractGoCommandError(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, loadErr error) (*source.Diagnostic, error) {
-	// We try to match module versions in error messages. Some examples:
-	//
-	//  err: exit status 1: stderr: go: example.com@v1.2.2: reading example.com/@v/v1.2.2.mod: no such file or directory
-	//  exit status 1: go: github.com/cockroachdb/apd/v2@v2.0.72: reading github.com/cockroachdb/apd/go.mod at revision v2.0.72: unknown revision v2.0.72
-	//
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	parsed, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gctx := goCommandErrorContext{
+		ctx:      ctx,
+		snapshot: snapshot,
+		fh:       fh,
+		parsed:   parsed,
+		mapper:   m,
+		loadErr:  loadErr,
+	}
+	for _, m := range matchGoCommandErrors(loadErr.Error()) {
+		diag, err := m.matcher.classify(gctx, m.match)
+		if err != nil {
+			// This matcher matched the text but couldn't resolve it to a
+			// go.mod line (or explicitly declined); fall through to the
+			// next, less specific matcher.
+			continue
+		}
+		if diag != nil {
+			return diag, nil
+		}
+	}
+	return nil, fmt.Errorf("no diagnostics for %v", loadErr)
+}
+
+// toDiagnostic builds a diagnostic covering the given modfile.Line, reporting
+// the go command's original error message.
+func toDiagnostic(gctx goCommandErrorContext, line *modfile.Line) (*source.Diagnostic, error) {
+	rng, err := rangeFromPositions(gctx.fh.URI(), gctx.mapper, line.Start, line.End)
+	if err != nil {
+		return nil, err
+	}
+	return &source.Diagnostic{
+		Message:  gctx.loadErr.Error(),
+		Range:    rng,
+		Severity: protocol.SeverityError,
+	}, nil
+}
+
+// relatedLine builds a RelatedInformation entry pointing at line, for
+// cross-referencing a diagnostic to another go.mod statement (e.g. the
+// replace directive that actually governs a require line's error).
+func relatedLine(gctx goCommandErrorContext, line *modfile.Line, message string) (source.RelatedInformation, error) {
+	rng, err := rangeFromPositions(gctx.fh.URI(), gctx.mapper, line.Start, line.End)
+	if err != nil {
+		return source.RelatedInformation{}, err
+	}
+	return source.RelatedInformation{
+		URI:     gctx.fh.URI(),
+		Range:   rng,
+		Message: message,
+	}, nil
+}
+
+// classifyModuleAtVersion handles the "unknown revision"/"no such file"
+// family of errors, which reference a bad module@version pair. We try to
+// find anything in the go.mod file -- a require, exclude, or replace -- that
+// matches it.
+func classifyModuleAtVersion(gctx goCommandErrorContext, _ []string) (*source.Diagnostic, error) {
+	// moduleAtVersionRe is a catch-all that also matches the module@version
+	// substring embedded in more specific errors (e.g. a checksum mismatch).
+	// goCommandErrorMatchers already orders this matcher last so those take
+	// priority, but decline explicitly too, in case this classifier is ever
+	// invoked directly or the table is reordered again.
+	for _, substr := range []string{"checksum mismatch", "missing go.sum entry", "ambiguous import"} {
+		if strings.Contains(gctx.loadErr.Error(), substr) {
+			return nil, fmt.Errorf("declining to classify %q as a bare module@version error", substr)
+		}
+	}
 	// We split on colons and attempt to match on something that matches
-	// module@version. If we're able to find a match, we try to find anything
-	// that matches it in the go.mod file.
+	// module@version, since the match passed in is taken from the whole
+	// error and may not be the relevant occurrence.
 	var v module.Version
-	for _, s := range strings.Split(loadErr.Error(), ":") {
+	for _, s := range strings.Split(gctx.loadErr.Error(), ":") {
 		s = strings.TrimSpace(s)
 		match := moduleAtVersionRe.FindStringSubmatch(s)
 		if match == nil || len(match) < 3 {
@@ -152,46 +492,177 @@ func ExtractGoCommandError(ctx context.Context, snapshot source.Snapshot, fh sou
 			break
 		}
 	}
-	pmh, err := snapshot.ParseModHandle(ctx, fh)
-	if err != nil {
-		return nil, err
+	// If a replace directive governs this module, the real fix is there even
+	// though the error surfaces on the require line -- point at it.
+	var replacedBy *modfile.Replace
+	for _, rep := range gctx.parsed.Replace {
+		if rep.Old == v {
+			replacedBy = rep
+			break
+		}
 	}
-	parsed, m, _, err := pmh.Parse(ctx)
-	if err != nil {
-		return nil, err
+	for _, req := range gctx.parsed.Require {
+		if req.Mod != v {
+			continue
+		}
+		diag, err := toDiagnostic(gctx, req.Syntax)
+		if err != nil {
+			return nil, err
+		}
+		if replacedBy != nil {
+			rel, err := relatedLine(gctx, replacedBy.Syntax, fmt.Sprintf("%s is replaced here", v.Path))
+			if err == nil {
+				diag.Related = append(diag.Related, rel)
+			}
+		}
+		return diag, nil
+	}
+	for _, ex := range gctx.parsed.Exclude {
+		if ex.Mod != v {
+			continue
+		}
+		return toDiagnostic(gctx, ex.Syntax)
 	}
-	toDiagnostic := func(line *modfile.Line) (*source.Diagnostic, error) {
-		rng, err := rangeFromPositions(fh.URI(), m, line.Start, line.End)
+	for _, rep := range gctx.parsed.Replace {
+		if rep.New != v && rep.Old != v {
+			continue
+		}
+		diag, err := toDiagnostic(gctx, rep.Syntax)
 		if err != nil {
 			return nil, err
 		}
-		return &source.Diagnostic{
-			Message:  loadErr.Error(),
-			Range:    rng,
-			Severity: protocol.SeverityError,
-		}, nil
+		for _, req := range gctx.parsed.Require {
+			if req.Mod != rep.Old {
+				continue
+			}
+			rel, err := relatedLine(gctx, req.Syntax, fmt.Sprintf("%s is required here", rep.Old.Path))
+			if err == nil {
+				diag.Related = append(diag.Related, rel)
+			}
+		}
+		return diag, nil
 	}
-	// Check if there are any require, exclude, or replace statements that
-	// match this module version.
-	for _, req := range parsed.Require {
-		if req.Mod != v {
+	return nil, fmt.Errorf("no require, exclude, or replace for %v", v)
+}
+
+// classifyMissingGoSumEntry handles "missing go.sum entry for module
+// providing package X", pointing at the require line that pulls in the
+// module that provides the package.
+//
+// No SuggestedFix is offered: the actual fix is `go mod download`, which
+// computes and writes a hash into go.sum -- there's no text edit to go.mod
+// that resolves this, and there's no registered LSP command gopls can
+// dispatch to run it on the user's behalf.
+func classifyMissingGoSumEntry(gctx goCommandErrorContext, match []string) (*source.Diagnostic, error) {
+	pkg := match[1]
+	for _, req := range gctx.parsed.Require {
+		if !packageWithinModule(pkg, req.Mod.Path) {
 			continue
 		}
-		return toDiagnostic(req.Syntax)
+		return toDiagnostic(gctx, req.Syntax)
 	}
-	for _, ex := range parsed.Exclude {
-		if ex.Mod != v {
+	return nil, fmt.Errorf("no require statement providing package %s", pkg)
+}
+
+// classifyAmbiguousImport handles "ambiguous import: found package X in
+// multiple modules", highlighting every require line whose module could be
+// the culprit and suggesting an exclude for all but one.
+func classifyAmbiguousImport(gctx goCommandErrorContext, match []string) (*source.Diagnostic, error) {
+	pkg := match[1]
+	var candidates []*modfile.Require
+	for _, req := range gctx.parsed.Require {
+		if packageWithinModule(pkg, req.Mod.Path) {
+			candidates = append(candidates, req)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no requires matching ambiguous package %s", pkg)
+	}
+	diag, err := toDiagnostic(gctx, candidates[0].Syntax)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range candidates[1:] {
+		rel, err := relatedLine(gctx, req.Syntax, fmt.Sprintf("%s also provides this package", req.Mod.Path))
+		if err == nil {
+			diag.Related = append(diag.Related, rel)
+		}
+		fix, err := excludeFix(gctx, req.Mod)
+		if err != nil {
 			continue
 		}
-		return toDiagnostic(ex.Syntax)
+		diag.SuggestedFixes = append(diag.SuggestedFixes, fix)
 	}
-	for _, rep := range parsed.Replace {
-		if rep.New != v && rep.Old != v {
+	return diag, nil
+}
+
+// excludeFix builds a SuggestedFix that appends an `exclude` directive for
+// mod to the end of go.mod, as a real text edit (rather than a command) so
+// that SuggestedFixes can surface it as a working quick fix on its own.
+func excludeFix(gctx goCommandErrorContext, mod module.Version) (source.SuggestedFix, error) {
+	_, end := gctx.parsed.Syntax.Span()
+	rng, err := rangeFromPositions(gctx.fh.URI(), gctx.mapper, end, end)
+	if err != nil {
+		return source.SuggestedFix{}, err
+	}
+	return source.SuggestedFix{
+		Title: fmt.Sprintf("Exclude %s to resolve the ambiguous import", mod.Path),
+		Edits: map[span.URI][]protocol.TextEdit{
+			gctx.fh.URI(): {
+				{
+					Range:   rng,
+					NewText: fmt.Sprintf("\nexclude %s %s\n", mod.Path, mod.Version),
+				},
+			},
+		},
+	}, nil
+}
+
+// classifyReplaceError handles a replace directive whose target module has a
+// different version than go.mod expects, as well as cyclic replace chains.
+func classifyReplaceError(gctx goCommandErrorContext, _ []string) (*source.Diagnostic, error) {
+	for _, rep := range gctx.parsed.Replace {
+		if strings.Contains(gctx.loadErr.Error(), rep.Old.Path) || strings.Contains(gctx.loadErr.Error(), rep.New.Path) {
+			diag, err := toDiagnostic(gctx, rep.Syntax)
+			if err != nil {
+				return nil, err
+			}
+			for _, req := range gctx.parsed.Require {
+				if req.Mod != rep.Old {
+					continue
+				}
+				rel, err := relatedLine(gctx, req.Syntax, fmt.Sprintf("%s is required here", rep.Old.Path))
+				if err == nil {
+					diag.Related = append(diag.Related, rel)
+				}
+			}
+			return diag, nil
+		}
+	}
+	if len(gctx.parsed.Replace) > 0 {
+		// We couldn't pin down which replace is at fault; fall back to the
+		// first one, which is still more useful than no diagnostic at all.
+		return toDiagnostic(gctx, gctx.parsed.Replace[0].Syntax)
+	}
+	return nil, fmt.Errorf("no replace directive for %v", gctx.loadErr)
+}
+
+// classifyChecksumMismatch handles "verifying module: checksum mismatch",
+// pointing at the require line.
+//
+// As with classifyMissingGoSumEntry, no SuggestedFix is offered: resolving
+// this means deleting and regenerating a go.sum entry via `go mod download`,
+// not a text edit to go.mod, and there's no registered LSP command to do
+// that on the user's behalf.
+func classifyChecksumMismatch(gctx goCommandErrorContext, match []string) (*source.Diagnostic, error) {
+	v := module.Version{Path: match[1], Version: match[2]}
+	for _, req := range gctx.parsed.Require {
+		if req.Mod != v {
 			continue
 		}
-		return toDiagnostic(rep.Syntax)
+		return toDiagnostic(gctx, req.Syntax)
 	}
-	return nil, fmt.Errorf("no diagnostics for %v", loadErr)
+	return nil, fmt.Errorf("no require statement for %v", v)
 }
 
 func rangeFromPositions(uri span.URI, m *protocol.ColumnMapper, s, e modfile.Position) (protocol.Range, error) {
@@ -0,0 +1,91 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import "testing"
+
+// TestMatchGoCommandErrorsOrder guards against the generic moduleAtVersionRe
+// catch-all shadowing more specific matchers: every one of the specific
+// error shapes below also contains a bare module@version substring, so if
+// moduleAtVersionRe isn't ordered (and declines) correctly, it would win
+// first and the specific matcher (and its fix) would never run.
+func TestMatchGoCommandErrorsOrder(t *testing.T) {
+	tests := []struct {
+		desc    string
+		err     string
+		wantOne string // name of the matcher that should match first
+	}{
+		{
+			desc:    "checksum mismatch",
+			err:     "go: verifying module: example.com/foo@v1.2.3: checksum mismatch",
+			wantOne: "checksumMismatch",
+		},
+		{
+			desc:    "missing go.sum entry",
+			err:     "missing go.sum entry for module providing package example.com/foo@v1.2.3 (imported by example.com/bar)",
+			wantOne: "missingGoSumEntry",
+		},
+		{
+			desc:    "ambiguous import",
+			err:     "ambiguous import: found package example.com/foo@v1.2.3 in multiple modules",
+			wantOne: "ambiguousImport",
+		},
+		{
+			desc:    "bare unknown revision falls through to the catch-all",
+			err:     "go: github.com/cockroachdb/apd/v2@v2.0.72: reading github.com/cockroachdb/apd/go.mod at revision v2.0.72: unknown revision v2.0.72",
+			wantOne: "moduleAtVersion",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			matches := matchGoCommandErrors(test.err)
+			if len(matches) == 0 {
+				t.Fatalf("matchGoCommandErrors(%q) = no matches, want at least one", test.err)
+			}
+			if got := matches[0].matcher.name; got != test.wantOne {
+				t.Errorf("matchGoCommandErrors(%q) first match = %q, want %q", test.err, got, test.wantOne)
+			}
+		})
+	}
+}
+
+// TestClassifyModuleAtVersionDeclines checks the belt-and-suspenders guard
+// in classifyModuleAtVersion: even if it were invoked directly on a message
+// that's really a more specific error, it should decline rather than
+// produce a generic, less useful diagnostic.
+func TestClassifyModuleAtVersionDeclines(t *testing.T) {
+	for _, msg := range []string{
+		"go: verifying module: example.com/foo@v1.2.3: checksum mismatch",
+		"missing go.sum entry for module providing package example.com/foo@v1.2.3",
+		"ambiguous import: found package example.com/foo@v1.2.3 in multiple modules",
+	} {
+		gctx := goCommandErrorContext{loadErr: errString(msg)}
+		if _, err := classifyModuleAtVersion(gctx, nil); err == nil {
+			t.Errorf("classifyModuleAtVersion(%q) = nil error, want it to decline", msg)
+		}
+	}
+}
+
+type errString string
+
+func (e errString) Error() string { return string(e) }
+
+func TestPackageWithinModule(t *testing.T) {
+	tests := []struct {
+		pkg, modPath string
+		want         bool
+	}{
+		{"example.com/foo", "example.com/foo", true},
+		{"example.com/foo/sub", "example.com/foo", true},
+		{"example.com/foobar/baz", "example.com/foo", false},
+		{"example.com/foobar", "example.com/foo", false},
+		{"example.com/foo", "example.com/bar", false},
+	}
+	for _, test := range tests {
+		if got := packageWithinModule(test.pkg, test.modPath); got != test.want {
+			t.Errorf("packageWithinModule(%q, %q) = %v, want %v", test.pkg, test.modPath, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// A Vuln describes one advisory affecting a module, in roughly the shape of
+// an OSV entry.
+type Vuln struct {
+	ID       string   // e.g. "GO-2022-0646"
+	Details  string   // human-readable summary
+	Fixed    string   // version fixing the vulnerable interval that contains the required version, if any
+	Affected []string // affected version ranges, for display
+}
+
+// A VulnDB looks up known vulnerabilities for a module@version. It is an
+// interface so that tests can inject fixtures instead of querying a live
+// database.
+type VulnDB interface {
+	// Lookup returns the vulnerabilities known to affect the given module
+	// version. It is expected to do network I/O and should not be called on
+	// a path that blocks diagnostics from being published.
+	Lookup(ctx context.Context, mod module.Version) ([]Vuln, error)
+}
+
+// DefaultVulnDB is the VulnDB used by VulnDiagnostics unless overridden,
+// backed by an OSV-style HTTP endpoint and an on-disk cache.
+var DefaultVulnDB VulnDB = &osvVulnDB{endpoint: "https://api.osv.dev/v1/query"}
+
+const vulnCacheTTL = 24 * time.Hour
+
+type vulnCacheEntry struct {
+	vulns   []Vuln
+	fetched time.Time
+}
+
+var (
+	vulnCacheMu sync.Mutex
+	vulnCache   = map[module.Version]vulnCacheEntry{}
+	// vulnInFlight tracks modules for which a background refreshVuln is
+	// already running, so repeated diagnostics passes (e.g. on every
+	// snapshot invalidation, which can be more frequent than a single OSV
+	// lookup takes) don't pile up duplicate concurrent requests.
+	vulnInFlight = map[module.Version]bool{}
+)
+
+// VulnDiagnostics reports known vulnerabilities affecting the modules
+// required by the view's go.mod, using DefaultVulnDB.
+//
+// Lookups are cached per (module, version) with a TTL, and a cache miss
+// triggers a background refresh rather than blocking: VulnDiagnostics
+// returns immediately with whatever is already cached. Results of a
+// background fetch appear only when VulnDiagnostics is next called; the
+// fetch completing does not itself invalidate or re-run any diagnostics.
+func VulnDiagnostics(ctx context.Context, snapshot source.Snapshot) (map[source.FileIdentity][]*source.Diagnostic, error) {
+	return vulnDiagnostics(ctx, snapshot, DefaultVulnDB)
+}
+
+func vulnDiagnostics(ctx context.Context, snapshot source.Snapshot, db VulnDB) (map[source.FileIdentity][]*source.Diagnostic, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil
+	}
+
+	ctx, done := event.Start(ctx, "mod.VulnDiagnostics", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	parsed, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := map[source.FileIdentity][]*source.Diagnostic{
+		fh.Identity(): {},
+	}
+	for _, req := range parsed.Require {
+		vulns, fresh := lookupCached(req.Mod)
+		if !fresh && startRefresh(req.Mod) {
+			// Don't block this diagnostics pass on network I/O: kick off a
+			// background fetch and move on. The next diagnostics pass will
+			// pick up the cached result.
+			go refreshVuln(db, req.Mod)
+		}
+		for _, v := range vulns {
+			diag, err := vulnDiagnostic(fh.URI(), parsed, m, req, v)
+			if err != nil {
+				return nil, err
+			}
+			reports[fh.Identity()] = append(reports[fh.Identity()], diag)
+		}
+	}
+	return reports, nil
+}
+
+func vulnDiagnostic(uri span.URI, parsed *modfile.File, m *protocol.ColumnMapper, req *modfile.Require, v Vuln) (*source.Diagnostic, error) {
+	rng, err := rangeFromPositions(uri, m, req.Syntax.Start, req.Syntax.End)
+	if err != nil {
+		return nil, err
+	}
+	diag := &source.Diagnostic{
+		Message:  fmt.Sprintf("%s: %s", v.ID, v.Details),
+		Range:    rng,
+		Severity: protocol.SeverityWarning,
+		Source:   "govulncheck",
+	}
+	if v.Fixed != "" {
+		// Replace the whole require line with one pinning the fixed version,
+		// as a real text edit: this only updates go.mod, the same way the
+		// other fixes in this package do, and leaves re-running `go mod
+		// tidy` (which will update go.sum) to the user, same as it would for
+		// a hand-edited go.mod.
+		line := req.Mod.Path + " " + v.Fixed
+		if !req.Syntax.InBlock {
+			line = "require " + line
+		}
+		diag.SuggestedFixes = []source.SuggestedFix{
+			{
+				Title: fmt.Sprintf("Upgrade %s to %s to fix %s", req.Mod.Path, v.Fixed, v.ID),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {
+						{
+							Range:   rng,
+							NewText: line,
+						},
+					},
+				},
+			},
+		}
+	} else {
+		// Append an exclude directive for the required version at the end of
+		// go.mod, the same way excludeFix does for ambiguous imports.
+		_, end := parsed.Syntax.Span()
+		if endRng, err := rangeFromPositions(uri, m, end, end); err == nil {
+			diag.SuggestedFixes = []source.SuggestedFix{
+				{
+					Title: fmt.Sprintf("Exclude %s %s (no fixed version available)", req.Mod.Path, req.Mod.Version),
+					Edits: map[span.URI][]protocol.TextEdit{
+						uri: {
+							{
+								Range:   endRng,
+								NewText: fmt.Sprintf("\nexclude %s %s\n", req.Mod.Path, req.Mod.Version),
+							},
+						},
+					},
+				},
+			}
+		}
+	}
+	return diag, nil
+}
+
+// lookupCached returns the cached vulnerabilities for mod, and whether the
+// cache entry is still within its TTL.
+func lookupCached(mod module.Version) ([]Vuln, bool) {
+	vulnCacheMu.Lock()
+	defer vulnCacheMu.Unlock()
+
+	entry, ok := vulnCache[mod]
+	if !ok {
+		return nil, false
+	}
+	return entry.vulns, time.Since(entry.fetched) < vulnCacheTTL
+}
+
+// startRefresh reports whether a refresh for mod should be started, and if
+// so, marks one as in flight so that concurrent diagnostics passes don't
+// also start one before it completes.
+func startRefresh(mod module.Version) bool {
+	vulnCacheMu.Lock()
+	defer vulnCacheMu.Unlock()
+	if vulnInFlight[mod] {
+		return false
+	}
+	vulnInFlight[mod] = true
+	return true
+}
+
+// refreshVuln fetches mod's vulnerabilities from db and stores them in the
+// cache. It is run in its own goroutine so that diagnostics are never
+// blocked on network I/O.
+func refreshVuln(db VulnDB, mod module.Version) {
+	defer func() {
+		vulnCacheMu.Lock()
+		delete(vulnInFlight, mod)
+		vulnCacheMu.Unlock()
+	}()
+
+	// Use a fresh, unbounded-by-the-request context: the fetch may outlive
+	// the diagnostics pass that triggered it.
+	vulns, err := db.Lookup(context.Background(), mod)
+	if err != nil {
+		return
+	}
+	vulnCacheMu.Lock()
+	vulnCache[mod] = vulnCacheEntry{vulns: vulns, fetched: time.Now()}
+	vulnCacheMu.Unlock()
+}
+
+// osvVulnDB queries an OSV-style JSON endpoint (see https://osv.dev), with a
+// file-backed cache under the user's cache directory so that repeated
+// lookups across gopls restarts don't all hit the network.
+type osvVulnDB struct {
+	endpoint string
+}
+
+type osvQuery struct {
+	Package osvPackage `json:"package"`
+	Version string     `json:"version"`
+}
+
+type osvPackage struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+type osvResponse struct {
+	Vulns []osvVuln `json:"vulns"`
+}
+
+type osvVuln struct {
+	ID       string `json:"id"`
+	Summary  string `json:"summary"`
+	Details  string `json:"details"`
+	Affected []struct {
+		Ranges []osvRange `json:"ranges"`
+	} `json:"affected"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced string `json:"introduced,omitempty"`
+	Fixed      string `json:"fixed,omitempty"`
+}
+
+// fixedVersionInRange walks a SEMVER range's events in order -- per the OSV
+// schema they alternate "introduced"/"fixed", describing a module's
+// vulnerable intervals, which lets the same vuln describe disjoint
+// intervals (e.g. vulnerable on both the v1 and v2 major-version branches,
+// with different fixes) -- and reports the Fixed version of whichever
+// interval actually contains version, if any. It returns ok=false if
+// version isn't covered by this range at all.
+func fixedVersionInRange(events []osvEvent, version string) (fixed string, ok bool) {
+	v := "v" + version
+	introduced := "0"
+	open := false
+	for _, e := range events {
+		if e.Introduced != "" {
+			introduced = e.Introduced
+			open = true
+		}
+		if e.Fixed != "" {
+			if open && versionInInterval(v, introduced, e.Fixed) {
+				return e.Fixed, true
+			}
+			open = false
+		}
+	}
+	// The last interval has no closing "fixed" event: version is still
+	// vulnerable, with no fix yet available on this branch.
+	if open && versionAtLeast(v, introduced) {
+		return "", true
+	}
+	return "", false
+}
+
+// versionAtLeast reports whether v >= introduced, treating the OSV
+// convention of introduced == "0" as -infinity.
+func versionAtLeast(v, introduced string) bool {
+	return introduced == "0" || semver.Compare(v, "v"+introduced) >= 0
+}
+
+// versionInInterval reports whether v falls within [introduced, fixed).
+func versionInInterval(v, introduced, fixed string) bool {
+	return versionAtLeast(v, introduced) && semver.Compare(v, "v"+fixed) < 0
+}
+
+func (db *osvVulnDB) Lookup(ctx context.Context, mod module.Version) ([]Vuln, error) {
+	if cached, ok := readVulnFileCache(mod); ok {
+		return cached, nil
+	}
+
+	query := osvQuery{
+		Package: osvPackage{Name: mod.Path, Ecosystem: "Go"},
+		Version: mod.Version,
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, db.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var osvResp osvResponse
+	if err := json.NewDecoder(resp.Body).Decode(&osvResp); err != nil {
+		return nil, err
+	}
+
+	var vulns []Vuln
+	for _, v := range osvResp.Vulns {
+		vuln := Vuln{ID: v.ID, Details: v.Summary}
+		if vuln.Details == "" {
+			vuln.Details = v.Details
+		}
+	affectedLoop:
+		for _, affected := range v.Affected {
+			for _, r := range affected.Ranges {
+				if r.Type != "SEMVER" {
+					continue
+				}
+				// OSV events carry bare versions ("1.2.3"); go.mod versions
+				// carry a "v" prefix.
+				if fixed, ok := fixedVersionInRange(r.Events, strings.TrimPrefix(mod.Version, "v")); ok {
+					if fixed != "" {
+						fixed = "v" + fixed
+					}
+					vuln.Fixed = fixed
+					break affectedLoop
+				}
+			}
+		}
+		vulns = append(vulns, vuln)
+	}
+
+	writeVulnFileCache(mod, vulns)
+	return vulns, nil
+}
+
+func vulnCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopls", "vulndb"), nil
+}
+
+func vulnCacheFile(mod module.Version) (string, error) {
+	dir, err := vulnCacheDir()
+	if err != nil {
+		return "", err
+	}
+	escaped, err := module.EscapePath(mod.Path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, escaped+"@"+mod.Version+".json"), nil
+}
+
+func readVulnFileCache(mod module.Version) ([]Vuln, bool) {
+	path, err := vulnCacheFile(mod)
+	if err != nil {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > vulnCacheTTL {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var vulns []Vuln
+	if err := json.Unmarshal(data, &vulns); err != nil {
+		return nil, false
+	}
+	return vulns, true
+}
+
+func writeVulnFileCache(mod module.Version, vulns []Vuln) {
+	path, err := vulnCacheFile(mod)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	data, err := json.Marshal(vulns)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+// countingVulnDB counts how many times Lookup is called, and blocks until
+// released so that concurrent callers can be made to overlap deterministically.
+type countingVulnDB struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (db *countingVulnDB) Lookup(ctx context.Context, mod module.Version) ([]Vuln, error) {
+	atomic.AddInt32(&db.calls, 1)
+	<-db.release
+	return nil, nil
+}
+
+// TestRefreshVulnDedupsInFlight guards against firing an unbounded number of
+// concurrent lookups for the same module while one is already outstanding:
+// of several startRefresh calls for the same module made while a refresh is
+// still outstanding, only the first should proceed.
+func TestRefreshVulnDedupsInFlight(t *testing.T) {
+	mod := module.Version{Path: "example.com/foo", Version: "v1.0.0"}
+	db := &countingVulnDB{release: make(chan struct{})}
+
+	const n = 5
+	var started int
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		if startRefresh(mod) {
+			started++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				refreshVuln(db, mod)
+			}()
+		}
+	}
+	if started != 1 {
+		t.Errorf("startRefresh succeeded %d times while a refresh was outstanding, want 1", started)
+	}
+
+	close(db.release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&db.calls); got != 1 {
+		t.Errorf("db.Lookup called %d times, want 1", got)
+	}
+
+	// Once the in-flight refresh has completed, startRefresh should allow a
+	// new one.
+	if !startRefresh(mod) {
+		t.Error("startRefresh() = false after the prior refresh completed, want true")
+	}
+}
+
+// TestFixedVersionInRange guards against picking the global minimum Fixed
+// version across every disjoint interval in a range, which can name a fix
+// from an incompatible branch (e.g. a v1 fix for a v2 module) that doesn't
+// actually remediate the installed version.
+func TestFixedVersionInRange(t *testing.T) {
+	// Vulnerable on v1.0.0-v1.4.0 (fixed in v1.4.0), reintroduced in v2, and
+	// still unfixed on the v2 branch as of v2.1.0.
+	events := []osvEvent{
+		{Introduced: "1.0.0"},
+		{Fixed: "1.4.0"},
+		{Introduced: "2.0.0"},
+	}
+
+	tests := []struct {
+		version   string
+		wantFixed string
+		wantOK    bool
+	}{
+		{"1.2.0", "1.4.0", true},
+		{"1.4.0", "", false}, // already fixed, outside the vulnerable interval
+		{"2.0.5", "", true},  // vulnerable on the v2 branch, no fix yet
+		{"0.9.0", "", false}, // predates the first introduced event
+	}
+	for _, test := range tests {
+		fixed, ok := fixedVersionInRange(events, test.version)
+		if fixed != test.wantFixed || ok != test.wantOK {
+			t.Errorf("fixedVersionInRange(%q) = (%q, %v), want (%q, %v)", test.version, fixed, ok, test.wantFixed, test.wantOK)
+		}
+	}
+}